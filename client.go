@@ -8,12 +8,11 @@
 package mhuclientgo
 
 import (
-	"crypto/tls"
 	"fmt"
+	"log/slog"
 	"net"
-	"os"
-	"strconv"
-	"strings"
+	"net/url"
+	"sync"
 	"time"
 )
 
@@ -21,28 +20,33 @@ type Message struct {
 	SubscriberID string `json:"subscriber_id"`
 	Topic        string `json:"topic"`
 	Payload      []byte `json:"payload"`
+	// Offset is a monotonically increasing, per-topic sequence number
+	// assigned by the hub. It lets a HubClient resume a topic from where
+	// it left off via SubscribeFrom and Ack.
+	Offset uint64 `json:"offset"`
 }
 
 type HubClient struct {
 	SubscriberID string
 	Topics       []string
 	Handler      func(Message)
-	Parser       func(string) (Message, bool)
+	Codec        Codec
+	Options      Options
+	OffsetStore  OffsetStore
+	TLSConfig    TLSConfig
+	Transport    Transport
+	Logger       *slog.Logger
 	Address      *net.TCPAddr
-	Conn         *tls.Conn
-	Debug        bool
-}
+	Conn         net.Conn
 
-func newTlsConfig() *tls.Config {
-	cert, err := tls.LoadX509KeyPair("certs/client.pem", "certs/client.key")
-	if err != nil {
-		panic(err)
-	}
-	config := tls.Config{
-		Certificates:       []tls.Certificate{cert},
-		InsecureSkipVerify: true,
-	}
-	return &config
+	handlers   *topicTrie
+	mu         sync.Mutex
+	topicsMu   sync.Mutex
+	outbox     chan []byte
+	done       chan struct{}
+	wg         sync.WaitGroup
+	writerOnce sync.Once
+	startOnce  sync.Once
 }
 
 func NewMessage(subscriberID, topic string, payload []byte) *Message {
@@ -53,120 +57,357 @@ func NewMessage(subscriberID, topic string, payload []byte) *Message {
 	}
 }
 
-// String message returns message as string
-// Format: subscriber_id.topic.payload
-func (m *Message) String() string {
-	return fmt.Sprintf("%s.%s.%s\n", m.SubscriberID, m.Topic, m.Payload)
-}
-
+// NewHubClient builds a HubClient for address, selecting its Transport from
+// the URL scheme: "tcp://" for plain TCP, "ws://"/"wss://" for WebSocket,
+// and "tls://" or a bare "host:port" (for backward compatibility) for TLS.
 func NewHubClient(address string) *HubClient {
-	addr, err := net.ResolveTCPAddr("tcp", address)
+	addr, scheme, err := parseAddress(address)
 	if err != nil {
 		panic(err)
 	}
 	h := &HubClient{
-		Address: addr,
-		Debug:   os.Getenv("DEBUB") == "true",
+		Address:     addr,
+		Codec:       JSONCodec{},
+		Options:     DefaultOptions(),
+		OffsetStore: NewMemoryOffsetStore(),
+		Logger:      NewNopLogger(),
+		handlers:    newTopicTrie(),
+		outbox:      make(chan []byte, 64),
+		done:        make(chan struct{}),
+	}
+	switch scheme {
+	case "tcp":
+		h.Transport = &TCPTransport{Address: addr.String()}
+	case "ws", "wss":
+		h.Transport = &WebSocketTransport{URL: address}
+	default: // "tls", or no scheme at all
+		h.Transport = &TLSTransport{Address: addr.String(), TLSConfig: &h.TLSConfig}
 	}
 	return h
 }
 
-func (h *HubClient) Publish(topic string, payload []byte) {
-	defer h.Conn.Close()
-	m := NewMessage(h.SubscriberID, topic, payload)
-	msg := m.String()
-	err := h.Connect()
+// parseAddress resolves address to a *net.TCPAddr and reports the URL
+// scheme it was given under, defaulting to "tls" when address has no
+// scheme (e.g. "hub.example.com:9000").
+func parseAddress(address string) (*net.TCPAddr, string, error) {
+	if u, err := url.Parse(address); err == nil && u.Scheme != "" && u.Host != "" {
+		addr, err := net.ResolveTCPAddr("tcp", u.Host)
+		if err != nil {
+			return nil, "", err
+		}
+		return addr, u.Scheme, nil
+	}
+	addr, err := net.ResolveTCPAddr("tcp", address)
 	if err != nil {
-		return
+		return nil, "", err
 	}
-	go h.Conn.Write([]byte(msg))
+	return addr, "tls", nil
 }
 
-func defaultParser(msg string) (m *Message, ok bool) {
-	msgSplit := strings.Split(msg, ".")
-	payload := []byte(msgSplit[2] + "." + msgSplit[3])
-	m = NewMessage(msgSplit[0], msgSplit[1], payload)
-	return m, true
+func contains(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
 }
 
-func (h *HubClient) parse(msg string) (m *Message, ok bool) {
-	if h.Parser == nil {
-		return defaultParser(msg)
+// addTopic appends topic to h.Topics if it isn't already there. It is the
+// only way h.Topics should be mutated, since Topics is read concurrently
+// from GetMessages's reconnect loop.
+func (h *HubClient) addTopic(topic string) {
+	h.topicsMu.Lock()
+	defer h.topicsMu.Unlock()
+	if !contains(h.Topics, topic) {
+		h.Topics = append(h.Topics, topic)
 	}
-	*m, ok = h.Parser(msg)
-	return m, ok
 }
 
-func (h *HubClient) getmessages() {
-	//defer h.Conn.Close()
-	for {
-		b := make([]byte, 1024)
-		_, err := h.Conn.Read(b)
+// removeTopic removes topic from h.Topics, if present.
+func (h *HubClient) removeTopic(topic string) {
+	h.topicsMu.Lock()
+	defer h.topicsMu.Unlock()
+	h.Topics = removeString(h.Topics, topic)
+}
+
+// topicsSnapshot returns a copy of h.Topics safe to range over without
+// holding topicsMu.
+func (h *HubClient) topicsSnapshot() []string {
+	h.topicsMu.Lock()
+	defer h.topicsMu.Unlock()
+	return append([]string(nil), h.Topics...)
+}
+
+// SubscribeFrom arranges for topic to resume delivery from offset on the
+// next (re)connect, overriding whatever OffsetStore already has recorded
+// for it.
+func (h *HubClient) SubscribeFrom(topic string, offset uint64) error {
+	h.addTopic(topic)
+	return h.OffsetStore.Save(topic, offset)
+}
+
+// Ack persists offset as the last successfully processed offset for topic,
+// so that a reconnect resumes delivery on topic after it instead of
+// redelivering already-handled messages. A client subscribed to more than
+// one topic (via Subscribe) must Ack each one separately; offsets are
+// tracked per topic, not per client.
+func (h *HubClient) Ack(topic string, offset uint64) error {
+	return h.OffsetStore.Save(topic, offset)
+}
+
+// sendResumeFrames tells the hub where to resume each subscribed topic
+// from, based on the offsets recorded in OffsetStore. Frames are handed to
+// enqueueFrame like every other outbound frame, so they can't interleave
+// mid-write with a frame writer() is sending concurrently.
+func (h *HubClient) sendResumeFrames() {
+	for _, topic := range h.topicsSnapshot() {
+		offset, err := h.OffsetStore.Load(topic)
 		if err != nil {
-			if h.Debug {
-				println("error reading: ", err)
+			h.notifyError(err)
+			continue
+		}
+		resume := Message{SubscriberID: h.SubscriberID, Topic: topic, Offset: offset, Payload: []byte("RESUME")}
+		frame, err := h.Codec.Encode(resume)
+		if err != nil {
+			h.notifyError(err)
+			continue
+		}
+		if err := h.enqueueFrame(frame); err != nil {
+			h.notifyError(err)
+		}
+	}
+}
+
+// enqueueFrame hands frame to the writer goroutine. It is the only way a
+// frame should reach the wire: control frames, resume frames, and
+// published frames all go through here so they're serialized against each
+// other on the single outbox/writer path instead of writing h.Conn from
+// whichever goroutine produced them.
+func (h *HubClient) enqueueFrame(frame []byte) error {
+	select {
+	case h.outbox <- frame:
+		return nil
+	case <-h.done:
+		return fmt.Errorf("mhuclientgo: client is closed")
+	}
+}
+
+// Publish enqueues a message for delivery on topic. It returns once the
+// message has been handed to the writer goroutine, not once it has reached
+// the hub; use Options.OnError to observe delivery failures.
+func (h *HubClient) Publish(topic string, payload []byte) error {
+	m := NewMessage(h.SubscriberID, topic, payload)
+	frame, err := h.Codec.Encode(*m)
+	if err != nil {
+		return err
+	}
+	if err := h.enqueueFrame(frame); err != nil {
+		return err
+	}
+	h.Logger.Debug("publish", "subscriber_id", h.SubscriberID, "topic", topic, "bytes", len(payload))
+	return nil
+}
+
+// writer drains outbox onto the current connection. It is the only
+// goroutine that writes to h.Conn, so publishing never races a reconnect.
+func (h *HubClient) writer() {
+	defer h.wg.Done()
+	for {
+		select {
+		case frame := <-h.outbox:
+			h.mu.Lock()
+			conn := h.Conn
+			h.mu.Unlock()
+			if conn == nil {
+				h.Logger.Warn("publish", "err", "no connection, retrying", "retry_in", h.Options.RetryInterval)
+				h.notifyError(fmt.Errorf("mhuclientgo: no connection, retrying publish in %s", h.Options.RetryInterval))
+				go h.requeueAfter(frame, h.Options.RetryInterval)
+				continue
 			}
-			break
-		} else {
-			inMsg := string(b)
-			if h.Debug {
-				println("got msg: ", inMsg)
+			if _, err := conn.Write(frame); err != nil {
+				h.Logger.Error("publish", "err", err)
+				h.notifyError(err)
 			}
+		case <-h.done:
+			return
+		}
+	}
+}
 
-			m, ok := h.parse(inMsg)
-			if !ok {
-				if h.Debug {
-					println("error parsing message: ", inMsg)
-				}
-				return
-			}
-			go h.Handler(*m)
+// requeueAfter puts frame back on outbox after d, so a frame dequeued
+// while disconnected is retried instead of silently dropped.
+func (h *HubClient) requeueAfter(frame []byte, d time.Duration) {
+	if d <= 0 {
+		d = time.Second
+	}
+	select {
+	case <-time.After(d):
+	case <-h.done:
+		return
+	}
+	select {
+	case h.outbox <- frame:
+	case <-h.done:
+	}
+}
+
+func (h *HubClient) getmessages() {
+	for {
+		m, err := h.Codec.Decode(h.Conn)
+		if err != nil {
+			h.Logger.Error("parse_error", "err", err)
+			h.notifyError(err)
+			return
 		}
-		//time.Sleep(1 * time.Second)
+		h.Logger.Debug("receive", "subscriber_id", m.SubscriberID, "topic", m.Topic, "offset", m.Offset, "bytes", len(m.Payload))
+		h.dispatch(m)
 	}
 }
 
+// ensureWriter starts the writer goroutine that drains outbox, if it
+// isn't already running.
+func (h *HubClient) ensureWriter() {
+	h.writerOnce.Do(func() {
+		h.wg.Add(1)
+		go h.writer()
+	})
+}
+
+// GetMessages connects to the hub and dispatches incoming messages to
+// Handler until Close is called, reconnecting with exponential backoff
+// whenever the connection drops. It blocks for as long as the client is
+// running, and also returns once Options.MaxRetries consecutive connection
+// attempts have failed; callers that only publish should call Start instead.
 func (h *HubClient) GetMessages() {
-	println("subscriber_id: ", h.SubscriberID)
+	h.ensureWriter()
+	h.run()
+}
+
+// Start begins connecting to the hub and maintaining that connection,
+// including reconnecting with backoff, in the background. Call it once for
+// a client that only publishes and never calls GetMessages — without a
+// caller driving the connection, Publish would enqueue frames that nothing
+// ever dials a connection to deliver.
+func (h *HubClient) Start() {
+	h.ensureWriter()
+	h.startOnce.Do(func() {
+		h.wg.Add(1)
+		go func() {
+			defer h.wg.Done()
+			h.run()
+		}()
+	})
+}
+
+// run is the connect/read loop shared by GetMessages (run in the caller's
+// own goroutine) and Start (run in a background goroutine).
+func (h *HubClient) run() {
 	for {
-		// err := h.Connect()
-		// if err == nil {
-		// 	h.getmessages()
-		// }
-		if err := h.Connect(); err == nil {
-			h.getmessages()
-		} else {
-			time.Sleep(10 * time.Second)
+		select {
+		case <-h.done:
+			return
+		default:
+		}
+		if err := h.connectWithBackoff(); err != nil {
+			return
+		}
+		h.resubscribeAll()
+		h.sendResumeFrames()
+		h.getmessages()
+		h.mu.Lock()
+		if h.Conn != nil {
+			h.Conn.Close()
+		}
+		h.Conn = nil
+		h.mu.Unlock()
+		h.Logger.Info("disconnect", "subscriber_id", h.SubscriberID)
+		if h.Options.OnDisconnect != nil {
+			h.Options.OnDisconnect(nil)
 		}
 	}
 }
 
+// connectWithBackoff retries Connect with exponential backoff and jitter
+// until it succeeds, Options.MaxRetries is exhausted, or the client is
+// closed.
+func (h *HubClient) connectWithBackoff() error {
+	attempt := 0
+	for {
+		select {
+		case <-h.done:
+			return fmt.Errorf("mhuclientgo: client is closed")
+		default:
+		}
+		err := h.Connect()
+		if err == nil {
+			h.Logger.Info("connect", "subscriber_id", h.SubscriberID)
+			if h.Options.OnConnect != nil {
+				h.Options.OnConnect()
+			}
+			return nil
+		}
+		h.notifyError(err)
+		attempt++
+		if h.Options.MaxRetries > 0 && attempt >= h.Options.MaxRetries {
+			return err
+		}
+		d := backoffDuration(attempt, h.Options.ReconnectInterval, h.Options.MaxReconnectInterval)
+		h.Logger.Warn("reconnect_attempt", "attempt", attempt, "backoff", d, "err", err)
+		select {
+		case <-time.After(d):
+		case <-h.done:
+			return fmt.Errorf("mhuclientgo: client is closed")
+		}
+	}
+}
+
+func (h *HubClient) notifyError(err error) {
+	if h.Options.OnError != nil {
+		h.Options.OnError(err)
+	}
+}
+
 func (h *HubClient) Connect() error {
-	config := newTlsConfig()
-	addr := net.JoinHostPort(h.Address.IP.String(), strconv.Itoa(h.Address.Port))
-	c, err := tls.Dial("tcp", addr, config)
-	if err == nil {
-		h.Conn = c
-	} else if h.Debug {
-		println("error connecting: ", err)
+	conn, err := h.Transport.Dial()
+	if err != nil {
 		return err
 	}
+	h.mu.Lock()
+	h.Conn = conn
+	h.mu.Unlock()
 	return nil
 }
 
+// Close tears down the client: it stops the writer goroutine, closes the
+// underlying connection, and causes GetMessages to return.
+func (h *HubClient) Close() error {
+	select {
+	case <-h.done:
+		return nil
+	default:
+		close(h.done)
+	}
+	h.mu.Lock()
+	conn := h.Conn
+	h.mu.Unlock()
+	var err error
+	if conn != nil {
+		err = conn.Close()
+	}
+	h.wg.Wait()
+	return err
+}
+
 // USAGE:
 /*
-	 debug := os.Getenv("DEBUG") == "true"
 	 hub_addr := os.Getenv("HUB_ADDR")
 
 	 handler := func(m Message) {
-		 if debug {
-			 println("sub: ", m.SubscriberID)
-			 println("topic: ", m.Topic)
-			 println("payload: ", m.Payload)
-		 }
+		 slog.Info("received", "sub", m.SubscriberID, "topic", m.Topic)
 	 }
 	 h := NewHubClient(hub_addr)
 	 h.SubscriberID = "3456"
 	 h.Handler = handler
+	 h.Logger = slog.New(slog.NewJSONHandler(os.Stdout, nil))
 */
@@ -0,0 +1,377 @@
+/*
+ * @file: codec.go
+ * @author: Jorge Quitério
+ * @copyright (c) 2021 Jorge Quitério
+ * @license: MIT
+ */
+
+package mhuclientgo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Codec encodes a Message into a wire frame and decodes one back out of a
+// stream. HubClient.Codec defaults to JSONCodec; ProtobufCodec and
+// MsgPackCodec are provided as drop-in alternatives for callers that want a
+// smaller or schema-stricter wire format.
+type Codec interface {
+	Encode(m Message) ([]byte, error)
+	Decode(r io.Reader) (Message, error)
+}
+
+// frame prepends body with a 4-byte big-endian length, so the reader on the
+// other end always knows exactly how many bytes to read for one message
+// regardless of payload content.
+func frame(body []byte) []byte {
+	buf := make([]byte, 4+len(body))
+	binary.BigEndian.PutUint32(buf[:4], uint32(len(body)))
+	copy(buf[4:], body)
+	return buf
+}
+
+// defaultMaxFrameSize bounds the length prefix trusted by readFrame when a
+// Codec's MaxFrameSize is left at its zero value: comfortably above any
+// single Message this client expects to (de)serialize, but far below
+// memory exhaustion territory for a hostile or corrupted length prefix.
+const defaultMaxFrameSize = 8 << 20 // 8 MiB
+
+// readFrame reads a 4-byte big-endian length prefix followed by exactly
+// that many bytes, rejecting a declared length over maxSize (or
+// defaultMaxFrameSize, if maxSize is <= 0) before allocating for it.
+func readFrame(r io.Reader, maxSize int) ([]byte, error) {
+	var hdr [4]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(hdr[:])
+	if maxSize <= 0 {
+		maxSize = defaultMaxFrameSize
+	}
+	if n > uint32(maxSize) {
+		return nil, fmt.Errorf("mhuclientgo: frame of %d bytes exceeds max frame size %d", n, maxSize)
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// JSONCodec encodes Messages as length-prefixed JSON. It is the default
+// Codec for a new HubClient.
+type JSONCodec struct {
+	// MaxFrameSize caps the length prefix Decode will trust, in bytes. Zero
+	// means defaultMaxFrameSize.
+	MaxFrameSize int
+}
+
+func (c JSONCodec) Encode(m Message) ([]byte, error) {
+	body, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return frame(body), nil
+}
+
+func (c JSONCodec) Decode(r io.Reader) (Message, error) {
+	body, err := readFrame(r, c.MaxFrameSize)
+	if err != nil {
+		return Message{}, err
+	}
+	var m Message
+	if err := json.Unmarshal(body, &m); err != nil {
+		return Message{}, err
+	}
+	return m, nil
+}
+
+// putVarint appends v to buf using protobuf's base-128 varint encoding.
+func putVarint(buf *bytes.Buffer, v uint64) {
+	for v >= 0x80 {
+		buf.WriteByte(byte(v) | 0x80)
+		v >>= 7
+	}
+	buf.WriteByte(byte(v))
+}
+
+// getVarint reads a base-128 varint from the start of b, returning the
+// value and the number of bytes it occupied, or 0 bytes if b does not hold
+// a complete varint.
+func getVarint(b []byte) (uint64, int) {
+	var v uint64
+	var shift uint
+	for i, c := range b {
+		v |= uint64(c&0x7f) << shift
+		if c&0x80 == 0 {
+			return v, i + 1
+		}
+		shift += 7
+	}
+	return 0, 0
+}
+
+func putLengthDelimitedField(buf *bytes.Buffer, fieldNum int, data []byte) {
+	putVarint(buf, uint64(fieldNum)<<3|2)
+	putVarint(buf, uint64(len(data)))
+	buf.Write(data)
+}
+
+// ProtobufCodec encodes Messages directly on the protobuf wire format
+// (field 1: subscriber_id, field 2: topic, field 3: payload, field 4:
+// offset), without depending on a generated .pb.go file.
+type ProtobufCodec struct {
+	// MaxFrameSize caps the length prefix Decode will trust, in bytes. Zero
+	// means defaultMaxFrameSize.
+	MaxFrameSize int
+}
+
+func (c ProtobufCodec) Encode(m Message) ([]byte, error) {
+	var buf bytes.Buffer
+	putLengthDelimitedField(&buf, 1, []byte(m.SubscriberID))
+	putLengthDelimitedField(&buf, 2, []byte(m.Topic))
+	putLengthDelimitedField(&buf, 3, m.Payload)
+	putVarint(&buf, uint64(4)<<3|0)
+	putVarint(&buf, m.Offset)
+	return frame(buf.Bytes()), nil
+}
+
+func (c ProtobufCodec) Decode(r io.Reader) (Message, error) {
+	body, err := readFrame(r, c.MaxFrameSize)
+	if err != nil {
+		return Message{}, err
+	}
+	var m Message
+	for len(body) > 0 {
+		tag, n := getVarint(body)
+		if n == 0 {
+			return Message{}, fmt.Errorf("mhuclientgo: truncated protobuf tag")
+		}
+		body = body[n:]
+		fieldNum, wireType := tag>>3, tag&0x7
+		switch wireType {
+		case 0: // varint
+			v, n := getVarint(body)
+			if n == 0 {
+				return Message{}, fmt.Errorf("mhuclientgo: truncated protobuf varint field %d", fieldNum)
+			}
+			body = body[n:]
+			if fieldNum == 4 {
+				m.Offset = v
+			}
+		case 2: // length-delimited
+			length, n := getVarint(body)
+			if n == 0 {
+				return Message{}, fmt.Errorf("mhuclientgo: truncated protobuf length")
+			}
+			body = body[n:]
+			if uint64(len(body)) < length {
+				return Message{}, fmt.Errorf("mhuclientgo: truncated protobuf field %d", fieldNum)
+			}
+			data := body[:length]
+			body = body[length:]
+			switch fieldNum {
+			case 1:
+				m.SubscriberID = string(data)
+			case 2:
+				m.Topic = string(data)
+			case 3:
+				m.Payload = append([]byte(nil), data...)
+			}
+		default:
+			return Message{}, fmt.Errorf("mhuclientgo: unsupported protobuf wire type %d", wireType)
+		}
+	}
+	return m, nil
+}
+
+// MsgPackCodec encodes Messages as a MessagePack fixmap
+// ({"subscriber_id":..., "topic":..., "payload":..., "offset":...}),
+// implementing just the subset of the MessagePack spec (fixmap, str, bin,
+// uint) that Message needs.
+type MsgPackCodec struct {
+	// MaxFrameSize caps the length prefix Decode will trust, in bytes. Zero
+	// means defaultMaxFrameSize.
+	MaxFrameSize int
+}
+
+func putMsgpackStr(buf *bytes.Buffer, s string) {
+	n := len(s)
+	switch {
+	case n < 32:
+		buf.WriteByte(0xa0 | byte(n))
+	case n < 1<<8:
+		buf.WriteByte(0xd9)
+		buf.WriteByte(byte(n))
+	case n < 1<<16:
+		buf.WriteByte(0xda)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(0xdb)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+	buf.WriteString(s)
+}
+
+func putMsgpackBin(buf *bytes.Buffer, b []byte) {
+	n := len(b)
+	switch {
+	case n < 1<<8:
+		buf.WriteByte(0xc4)
+		buf.WriteByte(byte(n))
+	case n < 1<<16:
+		buf.WriteByte(0xc5)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(0xc6)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+	buf.Write(b)
+}
+
+func getMsgpackStr(b []byte) (string, int, error) {
+	var n, hdr int
+	switch {
+	case len(b) == 0:
+		return "", 0, fmt.Errorf("mhuclientgo: truncated msgpack string")
+	case b[0]&0xe0 == 0xa0:
+		n, hdr = int(b[0]&0x1f), 1
+	case b[0] == 0xd9 && len(b) >= 2:
+		n, hdr = int(b[1]), 2
+	case b[0] == 0xda && len(b) >= 3:
+		n, hdr = int(binary.BigEndian.Uint16(b[1:3])), 3
+	case b[0] == 0xdb && len(b) >= 5:
+		n, hdr = int(binary.BigEndian.Uint32(b[1:5])), 5
+	default:
+		return "", 0, fmt.Errorf("mhuclientgo: not a msgpack string")
+	}
+	if len(b) < hdr+n {
+		return "", 0, fmt.Errorf("mhuclientgo: truncated msgpack string")
+	}
+	return string(b[hdr : hdr+n]), hdr + n, nil
+}
+
+func putMsgpackUint(buf *bytes.Buffer, v uint64) {
+	switch {
+	case v < 1<<7:
+		buf.WriteByte(byte(v))
+	case v < 1<<8:
+		buf.WriteByte(0xcc)
+		buf.WriteByte(byte(v))
+	case v < 1<<16:
+		buf.WriteByte(0xcd)
+		binary.Write(buf, binary.BigEndian, uint16(v))
+	case v < 1<<32:
+		buf.WriteByte(0xce)
+		binary.Write(buf, binary.BigEndian, uint32(v))
+	default:
+		buf.WriteByte(0xcf)
+		binary.Write(buf, binary.BigEndian, v)
+	}
+}
+
+func getMsgpackUint(b []byte) (uint64, int, error) {
+	switch {
+	case len(b) == 0:
+		return 0, 0, fmt.Errorf("mhuclientgo: truncated msgpack uint")
+	case b[0] < 0x80:
+		return uint64(b[0]), 1, nil
+	case b[0] == 0xcc && len(b) >= 2:
+		return uint64(b[1]), 2, nil
+	case b[0] == 0xcd && len(b) >= 3:
+		return uint64(binary.BigEndian.Uint16(b[1:3])), 3, nil
+	case b[0] == 0xce && len(b) >= 5:
+		return uint64(binary.BigEndian.Uint32(b[1:5])), 5, nil
+	case b[0] == 0xcf && len(b) >= 9:
+		return binary.BigEndian.Uint64(b[1:9]), 9, nil
+	default:
+		return 0, 0, fmt.Errorf("mhuclientgo: not a msgpack uint")
+	}
+}
+
+func getMsgpackBin(b []byte) ([]byte, int, error) {
+	var n, hdr int
+	switch {
+	case len(b) == 0:
+		return nil, 0, fmt.Errorf("mhuclientgo: truncated msgpack bin")
+	case b[0] == 0xc4 && len(b) >= 2:
+		n, hdr = int(b[1]), 2
+	case b[0] == 0xc5 && len(b) >= 3:
+		n, hdr = int(binary.BigEndian.Uint16(b[1:3])), 3
+	case b[0] == 0xc6 && len(b) >= 5:
+		n, hdr = int(binary.BigEndian.Uint32(b[1:5])), 5
+	default:
+		return nil, 0, fmt.Errorf("mhuclientgo: not a msgpack bin")
+	}
+	if len(b) < hdr+n {
+		return nil, 0, fmt.Errorf("mhuclientgo: truncated msgpack bin")
+	}
+	return append([]byte(nil), b[hdr:hdr+n]...), hdr + n, nil
+}
+
+func (c MsgPackCodec) Encode(m Message) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte(0x84) // fixmap, 4 entries
+	putMsgpackStr(&buf, "subscriber_id")
+	putMsgpackStr(&buf, m.SubscriberID)
+	putMsgpackStr(&buf, "topic")
+	putMsgpackStr(&buf, m.Topic)
+	putMsgpackStr(&buf, "payload")
+	putMsgpackBin(&buf, m.Payload)
+	putMsgpackStr(&buf, "offset")
+	putMsgpackUint(&buf, m.Offset)
+	return frame(buf.Bytes()), nil
+}
+
+func (c MsgPackCodec) Decode(r io.Reader) (Message, error) {
+	body, err := readFrame(r, c.MaxFrameSize)
+	if err != nil {
+		return Message{}, err
+	}
+	if len(body) == 0 || body[0]&0xf0 != 0x80 {
+		return Message{}, fmt.Errorf("mhuclientgo: expected msgpack fixmap")
+	}
+	count := int(body[0] & 0x0f)
+	body = body[1:]
+	var m Message
+	for i := 0; i < count; i++ {
+		key, n, err := getMsgpackStr(body)
+		if err != nil {
+			return Message{}, err
+		}
+		body = body[n:]
+		switch key {
+		case "subscriber_id":
+			v, n, err := getMsgpackStr(body)
+			if err != nil {
+				return Message{}, err
+			}
+			m.SubscriberID, body = v, body[n:]
+		case "topic":
+			v, n, err := getMsgpackStr(body)
+			if err != nil {
+				return Message{}, err
+			}
+			m.Topic, body = v, body[n:]
+		case "payload":
+			v, n, err := getMsgpackBin(body)
+			if err != nil {
+				return Message{}, err
+			}
+			m.Payload, body = v, body[n:]
+		case "offset":
+			v, n, err := getMsgpackUint(body)
+			if err != nil {
+				return Message{}, err
+			}
+			m.Offset, body = v, body[n:]
+		default:
+			return Message{}, fmt.Errorf("mhuclientgo: unexpected msgpack key %q", key)
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,88 @@
+/*
+ * @file: codec_test.go
+ * @author: Jorge Quitério
+ * @copyright (c) 2021 Jorge Quitério
+ * @license: MIT
+ */
+
+package mhuclientgo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestCodecRoundTrip(t *testing.T) {
+	msg := Message{
+		SubscriberID: "sub-1",
+		Topic:        "sensors/room1/temp",
+		Payload:      []byte("23.5"),
+		Offset:       42,
+	}
+
+	codecs := map[string]Codec{
+		"JSON":     JSONCodec{},
+		"Protobuf": ProtobufCodec{},
+		"MsgPack":  MsgPackCodec{},
+	}
+
+	for name, codec := range codecs {
+		t.Run(name, func(t *testing.T) {
+			frame, err := codec.Encode(msg)
+			if err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+			got, err := codec.Decode(bytes.NewReader(frame))
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if got.SubscriberID != msg.SubscriberID || got.Topic != msg.Topic ||
+				!bytes.Equal(got.Payload, msg.Payload) || got.Offset != msg.Offset {
+				t.Fatalf("round trip mismatch: got %+v, want %+v", got, msg)
+			}
+		})
+	}
+}
+
+func TestCodecRoundTripEmptyPayload(t *testing.T) {
+	msg := Message{SubscriberID: "s", Topic: "t"}
+	for name, codec := range map[string]Codec{
+		"JSON":     JSONCodec{},
+		"Protobuf": ProtobufCodec{},
+		"MsgPack":  MsgPackCodec{},
+	} {
+		t.Run(name, func(t *testing.T) {
+			frame, err := codec.Encode(msg)
+			if err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+			got, err := codec.Decode(bytes.NewReader(frame))
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if got.SubscriberID != msg.SubscriberID || got.Topic != msg.Topic || len(got.Payload) != 0 {
+				t.Fatalf("round trip mismatch: got %+v, want %+v", got, msg)
+			}
+		})
+	}
+}
+
+func TestReadFrameRejectsOversizedLength(t *testing.T) {
+	var hdr [4]byte
+	binary.BigEndian.PutUint32(hdr[:], 0xFFFFFFF0)
+	r := bytes.NewReader(hdr[:])
+
+	if _, err := (JSONCodec{}).Decode(r); err == nil {
+		t.Fatal("expected an error for a length prefix over the max frame size, got nil")
+	}
+}
+
+func TestReadFrameHonorsCustomMaxFrameSize(t *testing.T) {
+	body := bytes.Repeat([]byte("x"), 100)
+	f := frame(body)
+
+	if _, err := (JSONCodec{MaxFrameSize: 10}).Decode(bytes.NewReader(f)); err == nil {
+		t.Fatal("expected an error when the frame exceeds a codec's configured MaxFrameSize")
+	}
+}
@@ -0,0 +1,20 @@
+/*
+ * @file: logger.go
+ * @author: Jorge Quitério
+ * @copyright (c) 2021 Jorge Quitério
+ * @license: MIT
+ */
+
+package mhuclientgo
+
+import (
+	"io"
+	"log/slog"
+)
+
+// NewNopLogger returns a *slog.Logger that discards all output. It is the
+// default Logger for a new HubClient, so the client stays silent unless a
+// caller opts into logging.
+func NewNopLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
@@ -0,0 +1,103 @@
+/*
+ * @file: offset.go
+ * @author: Jorge Quitério
+ * @copyright (c) 2021 Jorge Quitério
+ * @license: MIT
+ */
+
+package mhuclientgo
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// OffsetStore persists, per topic, the last offset a HubClient has
+// acknowledged processing. On reconnect the client resumes each topic from
+// its stored offset instead of redelivering from the start of the stream.
+type OffsetStore interface {
+	Load(topic string) (uint64, error)
+	Save(topic string, offset uint64) error
+}
+
+// MemoryOffsetStore is an OffsetStore backed by an in-memory map. It is the
+// default OffsetStore for a new HubClient; offsets are lost when the
+// process exits, so at-least-once delivery only survives reconnects, not
+// restarts.
+type MemoryOffsetStore struct {
+	mu      sync.Mutex
+	offsets map[string]uint64
+}
+
+func NewMemoryOffsetStore() *MemoryOffsetStore {
+	return &MemoryOffsetStore{offsets: make(map[string]uint64)}
+}
+
+func (s *MemoryOffsetStore) Load(topic string) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.offsets[topic], nil
+}
+
+func (s *MemoryOffsetStore) Save(topic string, offset uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.offsets[topic] = offset
+	return nil
+}
+
+// FileOffsetStore is an OffsetStore backed by a single JSON file, read and
+// rewritten in full on every Save, so offsets survive a process restart.
+// It is meant for a single process at a time; it does not coordinate
+// access across processes.
+type FileOffsetStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFileOffsetStore(path string) *FileOffsetStore {
+	return &FileOffsetStore{path: path}
+}
+
+func (s *FileOffsetStore) load() (map[string]uint64, error) {
+	offsets := make(map[string]uint64)
+	b, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return offsets, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return offsets, nil
+	}
+	if err := json.Unmarshal(b, &offsets); err != nil {
+		return nil, err
+	}
+	return offsets, nil
+}
+
+func (s *FileOffsetStore) Load(topic string) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	offsets, err := s.load()
+	if err != nil {
+		return 0, err
+	}
+	return offsets[topic], nil
+}
+
+func (s *FileOffsetStore) Save(topic string, offset uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	offsets, err := s.load()
+	if err != nil {
+		return err
+	}
+	offsets[topic] = offset
+	b, err := json.Marshal(offsets)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, b, 0o600)
+}
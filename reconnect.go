@@ -0,0 +1,78 @@
+/*
+ * @file: reconnect.go
+ * @author: Jorge Quitério
+ * @copyright (c) 2021 Jorge Quitério
+ * @license: MIT
+ */
+
+package mhuclientgo
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Options controls how a HubClient reconnects to the hub and lets callers
+// observe its lifecycle.
+type Options struct {
+	// ReconnectInterval is the base delay between reconnect attempts; it
+	// doubles after every failed attempt up to MaxReconnectInterval.
+	ReconnectInterval time.Duration
+	// MaxReconnectInterval caps the exponential backoff applied between
+	// reconnect attempts.
+	MaxReconnectInterval time.Duration
+	// RetryInterval is the delay applied between retries of a single
+	// operation (e.g. a failed publish) that is not a full reconnect.
+	RetryInterval time.Duration
+	// MaxRetries is the number of consecutive failed connection attempts
+	// GetMessages tolerates before giving up. Zero means retry forever.
+	MaxRetries int
+
+	// OnConnect, when set, is called after every successful connection.
+	OnConnect func()
+	// OnDisconnect, when set, is called whenever the connection is lost.
+	OnDisconnect func(err error)
+	// OnError, when set, is called for connection and I/O errors that
+	// GetMessages and Publish would otherwise only surface through Logger.
+	OnError func(err error)
+}
+
+// DefaultOptions returns the Options used by a new HubClient: a one second
+// base backoff doubling up to 30 seconds, and unlimited retries.
+func DefaultOptions() Options {
+	return Options{
+		ReconnectInterval:    time.Second,
+		MaxReconnectInterval: 30 * time.Second,
+		RetryInterval:        time.Second,
+		MaxRetries:           0,
+	}
+}
+
+// backoffDuration returns the delay to wait before reconnect attempt
+// number attempt (1-based), doubling base each attempt up to max and then
+// picking a random delay between 50% and 100% of that value, so that many
+// clients reconnecting at once don't stampede the hub in lockstep. max <= 0 (e.g. Options set by hand rather
+// than via DefaultOptions) is treated as "use a sane default cap" rather
+// than "uncapped", so a long-running outage can never double d past the
+// point where it would overflow time.Duration.
+func backoffDuration(attempt int, base, max time.Duration) time.Duration {
+	if base <= 0 {
+		base = time.Second
+	}
+	if max <= 0 {
+		max = 30 * time.Second
+	}
+	d := base
+	for i := 1; i < attempt; i++ {
+		if d >= max {
+			d = max
+			break
+		}
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	jitter := time.Duration(rand.Int63n(int64(d)/2 + 1))
+	return d/2 + jitter
+}
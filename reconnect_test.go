@@ -0,0 +1,47 @@
+/*
+ * @file: reconnect_test.go
+ * @author: Jorge Quitério
+ * @copyright (c) 2021 Jorge Quitério
+ * @license: MIT
+ */
+
+package mhuclientgo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffDurationStaysBounded(t *testing.T) {
+	base := 100 * time.Millisecond
+	max := 5 * time.Second
+	for attempt := 1; attempt <= 1000; attempt++ {
+		d := backoffDuration(attempt, base, max)
+		if d < 0 {
+			t.Fatalf("attempt %d: backoffDuration returned negative duration %s", attempt, d)
+		}
+		if d > max {
+			t.Fatalf("attempt %d: backoffDuration returned %s, want <= max %s", attempt, d, max)
+		}
+	}
+}
+
+func TestBackoffDurationZeroMaxUsesDefaultCap(t *testing.T) {
+	const defaultCap = 30 * time.Second
+	for attempt := 1; attempt <= 1000; attempt++ {
+		d := backoffDuration(attempt, time.Second, 0)
+		if d < 0 {
+			t.Fatalf("attempt %d: backoffDuration returned negative duration %s", attempt, d)
+		}
+		if d > defaultCap {
+			t.Fatalf("attempt %d: backoffDuration returned %s, want <= default cap %s", attempt, d, defaultCap)
+		}
+	}
+}
+
+func TestBackoffDurationZeroBaseUsesOneSecond(t *testing.T) {
+	d := backoffDuration(1, 0, time.Minute)
+	if d <= 0 || d > time.Second {
+		t.Fatalf("backoffDuration(1, 0, time.Minute) = %s, want in (0, 1s]", d)
+	}
+}
@@ -0,0 +1,165 @@
+/*
+ * @file: subscribe.go
+ * @author: Jorge Quitério
+ * @copyright (c) 2021 Jorge Quitério
+ * @license: MIT
+ */
+
+package mhuclientgo
+
+import (
+	"strings"
+	"sync"
+)
+
+// topicTrie matches MQTT-style topic filters, split on "/", against
+// published topics: "+" matches exactly one level and "#" (only valid as
+// the last level of a filter) matches any number of trailing levels,
+// including none, so "logs/#" also matches "logs".
+type topicTrie struct {
+	mu   sync.RWMutex
+	root *trieNode
+}
+
+type trieNode struct {
+	children map[string]*trieNode
+	handler  func(Message)
+	isLeaf   bool
+}
+
+func newTopicTrie() *topicTrie {
+	return &topicTrie{root: &trieNode{children: make(map[string]*trieNode)}}
+}
+
+func splitTopic(topic string) []string {
+	return strings.Split(topic, "/")
+}
+
+func (t *topicTrie) insert(filter string, handler func(Message)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	node := t.root
+	for _, level := range splitTopic(filter) {
+		child, ok := node.children[level]
+		if !ok {
+			child = &trieNode{children: make(map[string]*trieNode)}
+			node.children[level] = child
+		}
+		node = child
+	}
+	node.handler = handler
+	node.isLeaf = true
+}
+
+func (t *topicTrie) remove(filter string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	node := t.root
+	for _, level := range splitTopic(filter) {
+		child, ok := node.children[level]
+		if !ok {
+			return
+		}
+		node = child
+	}
+	node.handler = nil
+	node.isLeaf = false
+}
+
+// match returns the handler registered for the most specific filter that
+// matches topic, if any.
+func (t *topicTrie) match(topic string) (func(Message), bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return matchNode(t.root, splitTopic(topic))
+}
+
+func matchNode(node *trieNode, levels []string) (func(Message), bool) {
+	if len(levels) == 0 {
+		if node.isLeaf {
+			return node.handler, true
+		}
+		if child, ok := node.children["#"]; ok && child.isLeaf {
+			return child.handler, true
+		}
+		return nil, false
+	}
+	level, rest := levels[0], levels[1:]
+	if child, ok := node.children[level]; ok {
+		if h, ok := matchNode(child, rest); ok {
+			return h, true
+		}
+	}
+	if child, ok := node.children["+"]; ok {
+		if h, ok := matchNode(child, rest); ok {
+			return h, true
+		}
+	}
+	if child, ok := node.children["#"]; ok && child.isLeaf {
+		return child.handler, true
+	}
+	return nil, false
+}
+
+func removeString(ss []string, s string) []string {
+	out := ss[:0]
+	for _, v := range ss {
+		if v != s {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// Subscribe registers handler for topic, which may use MQTT-style
+// wildcards ("+" for a single level, "#" for the remaining levels), and
+// tells the hub to start delivering it. Messages on topics with no
+// matching per-topic handler fall back to Handler.
+func (h *HubClient) Subscribe(topic string, handler func(Message)) error {
+	h.handlers.insert(topic, handler)
+	h.addTopic(topic)
+	return h.sendControlFrame("SUBSCRIBE", topic)
+}
+
+// Unsubscribe removes the handler registered for topic and tells the hub
+// to stop delivering it.
+func (h *HubClient) Unsubscribe(topic string) error {
+	h.handlers.remove(topic)
+	h.removeTopic(topic)
+	return h.sendControlFrame("UNSUBSCRIBE", topic)
+}
+
+// sendControlFrame sends a SUBSCRIBE/UNSUBSCRIBE frame for topic. Like
+// every other outbound frame it goes through enqueueFrame, so it can't
+// interleave mid-write with a frame writer() is sending concurrently.
+func (h *HubClient) sendControlFrame(kind, topic string) error {
+	frame, err := h.Codec.Encode(Message{SubscriberID: h.SubscriberID, Topic: topic, Payload: []byte(kind)})
+	if err != nil {
+		return err
+	}
+	return h.enqueueFrame(frame)
+}
+
+// resubscribeAll re-sends a SUBSCRIBE frame for every subscribed topic. It
+// runs after every (re)connect, since a fresh connection to the hub starts
+// with no subscriptions.
+func (h *HubClient) resubscribeAll() {
+	for _, topic := range h.topicsSnapshot() {
+		if err := h.sendControlFrame("SUBSCRIBE", topic); err != nil {
+			h.Logger.Error("subscribe", "topic", topic, "err", err)
+			h.notifyError(err)
+		}
+	}
+}
+
+// dispatch delivers m to the most specific per-topic handler registered
+// via Subscribe, falling back to Handler when no filter matches.
+func (h *HubClient) dispatch(m Message) {
+	if handler, ok := h.handlers.match(m.Topic); ok {
+		go handler(m)
+		return
+	}
+	if h.Handler != nil {
+		go h.Handler(m)
+	}
+}
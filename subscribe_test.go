@@ -0,0 +1,49 @@
+/*
+ * @file: subscribe_test.go
+ * @author: Jorge Quitério
+ * @copyright (c) 2021 Jorge Quitério
+ * @license: MIT
+ */
+
+package mhuclientgo
+
+import "testing"
+
+func TestTopicTrieMatch(t *testing.T) {
+	trie := newTopicTrie()
+	trie.insert("sensors/room1/temp", func(Message) {})
+	trie.insert("sensors/+/humidity", func(Message) {})
+	trie.insert("logs/#", func(Message) {})
+
+	cases := []struct {
+		name  string
+		topic string
+		want  bool
+	}{
+		{"exact match", "sensors/room1/temp", true},
+		{"single-level wildcard", "sensors/room2/humidity", true},
+		{"single-level wildcard does not span levels", "sensors/room2/extra/humidity", false},
+		{"multi-level wildcard", "logs/app/error", true},
+		{"multi-level wildcard matches the top level itself", "logs", true},
+		{"no registered filter matches", "sensors/room1/pressure", false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, ok := trie.match(c.topic)
+			if ok != c.want {
+				t.Fatalf("match(%q) = %v, want %v", c.topic, ok, c.want)
+			}
+		})
+	}
+}
+
+func TestTopicTrieRemove(t *testing.T) {
+	trie := newTopicTrie()
+	trie.insert("sensors/room1/temp", func(Message) {})
+	trie.remove("sensors/room1/temp")
+
+	if _, ok := trie.match("sensors/room1/temp"); ok {
+		t.Fatal("match succeeded after remove")
+	}
+}
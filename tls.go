@@ -0,0 +1,114 @@
+/*
+ * @file: tls.go
+ * @author: Jorge Quitério
+ * @copyright (c) 2021 Jorge Quitério
+ * @license: MIT
+ */
+
+package mhuclientgo
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"os"
+)
+
+// TLSConfig configures the TLS connection a HubClient dials. The zero
+// value presents no client certificate and verifies the server
+// certificate against the system root pool, i.e. it is safe by default.
+type TLSConfig struct {
+	// CertFile and KeyFile, if both set, are loaded as a client
+	// certificate for mutual TLS.
+	CertFile string
+	KeyFile  string
+	// CAFile, if set, is a PEM file of CA certificates to verify the
+	// server against, instead of the system root pool.
+	CAFile string
+	// CACertPool, if set, takes precedence over CAFile.
+	CACertPool *x509.CertPool
+	// ServerName overrides the hostname used for SNI and certificate
+	// verification.
+	ServerName string
+	// InsecureSkipVerify disables server certificate verification. It
+	// defaults to false; only set it for tests against a hub that has no
+	// usable certificate.
+	InsecureSkipVerify bool
+	// SPKIPins, when non-empty, additionally requires at least one
+	// certificate in the chain the server presents to have a
+	// SubjectPublicKeyInfo that hashes (SHA-256, base64) to one of these
+	// values, rejecting a chain with no such certificate even if it
+	// otherwise verifies.
+	SPKIPins []string
+
+	tlsConfig *tls.Config
+}
+
+// WithTLSConfig lets a caller supply a fully assembled *tls.Config,
+// bypassing CertFile, KeyFile, CAFile, CACertPool, ServerName,
+// InsecureSkipVerify, and SPKIPins.
+func (t *TLSConfig) WithTLSConfig(c *tls.Config) {
+	t.tlsConfig = c
+}
+
+// build assembles a *tls.Config from t, loading certificate and CA
+// material lazily so that constructing a HubClient never panics when no
+// certs are present (e.g. tests that use plain TCP).
+func (t *TLSConfig) build() (*tls.Config, error) {
+	if t.tlsConfig != nil {
+		return t.tlsConfig, nil
+	}
+	config := &tls.Config{
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+	if t.CertFile != "" || t.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("mhuclientgo: loading client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	switch {
+	case t.CACertPool != nil:
+		config.RootCAs = t.CACertPool
+	case t.CAFile != "":
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("mhuclientgo: reading CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("mhuclientgo: no certificates found in %s", t.CAFile)
+		}
+		config.RootCAs = pool
+	}
+	if len(t.SPKIPins) > 0 {
+		pins := t.SPKIPins
+		config.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return verifySPKIPin(rawCerts, pins)
+		}
+	}
+	return config, nil
+}
+
+// verifySPKIPin reports an error unless at least one of rawCerts hashes to
+// one of pins.
+func verifySPKIPin(rawCerts [][]byte, pins []string) error {
+	for _, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			continue
+		}
+		sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+		pin := base64.StdEncoding.EncodeToString(sum[:])
+		for _, want := range pins {
+			if pin == want {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("mhuclientgo: no certificate matched the configured SPKI pins")
+}
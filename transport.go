@@ -0,0 +1,70 @@
+/*
+ * @file: transport.go
+ * @author: Jorge Quitério
+ * @copyright (c) 2021 Jorge Quitério
+ * @license: MIT
+ */
+
+package mhuclientgo
+
+import (
+	"crypto/tls"
+	"net"
+
+	"golang.org/x/net/websocket"
+)
+
+// Transport dials the hub and returns the connection Publish and
+// GetMessages read and write framed messages over.
+type Transport interface {
+	Dial() (net.Conn, error)
+}
+
+// TCPTransport dials a plain, unencrypted TCP connection. It exists for
+// tests and for hubs reachable only over an already-trusted network.
+type TCPTransport struct {
+	Address string
+}
+
+func (t *TCPTransport) Dial() (net.Conn, error) {
+	return net.Dial("tcp", t.Address)
+}
+
+// TLSTransport dials a TLS connection configured by TLSConfig. It is the
+// default transport, matching the client's original behavior.
+type TLSTransport struct {
+	Address   string
+	TLSConfig *TLSConfig
+}
+
+func (t *TLSTransport) Dial() (net.Conn, error) {
+	config, err := t.TLSConfig.build()
+	if err != nil {
+		return nil, err
+	}
+	return tls.Dial("tcp", t.Address, config)
+}
+
+// WebSocketTransport dials the hub over WebSocket, letting the client run
+// behind HTTP reverse proxies and load balancers that only pass through
+// HTTP(S), or in browser-adjacent environments. URL is the full ws:// or
+// wss:// address.
+type WebSocketTransport struct {
+	URL string
+	// Origin is sent as the WebSocket handshake's Origin header. It
+	// defaults to "http://localhost" when empty.
+	Origin string
+}
+
+func (t *WebSocketTransport) Dial() (net.Conn, error) {
+	origin := t.Origin
+	if origin == "" {
+		origin = "http://localhost"
+	}
+	conn, err := websocket.Dial(t.URL, "", origin)
+	if err != nil {
+		return nil, err
+	}
+	conn.PayloadType = websocket.BinaryFrame
+	return conn, nil
+}